@@ -0,0 +1,72 @@
+package dice
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Format renders the result as a string. With verbose set to false, it
+// returns just the total, e.g. "13". With verbose set to true, it
+// returns a transcript of how that total was reached, e.g.
+// "[4, 6] + 3 = 13", including any dice dropped by a keep/drop
+// modifier. Dice that came from a subtracted term (e.g. the 1d6 in
+// "1d8 - 1d6") are listed in their own bracket after a "-", so the
+// transcript still reflects which dice added to the total and which
+// were taken away.
+func (res *Result) Format(verbose bool) string {
+	if !verbose {
+		return strconv.Itoa(res.Roll)
+	}
+
+	added, subtracted := res.Dice, []int(nil)
+	if res.diceNeg != nil {
+		added, subtracted = nil, nil
+		for i, d := range res.Dice {
+			if res.diceNeg[i] {
+				subtracted = append(subtracted, d)
+			} else {
+				added = append(added, d)
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteByte('[')
+	writeInts(&b, added)
+	b.WriteByte(']')
+
+	if len(subtracted) > 0 {
+		b.WriteString(" - [")
+		writeInts(&b, subtracted)
+		b.WriteByte(']')
+	}
+
+	if len(res.Dropped) > 0 {
+		b.WriteString(" (dropped ")
+		writeInts(&b, res.Dropped)
+		b.WriteByte(')')
+	}
+
+	if res.Modifier > 0 {
+		b.WriteString(" + ")
+		b.WriteString(strconv.Itoa(res.Modifier))
+	} else if res.Modifier < 0 {
+		b.WriteString(" - ")
+		b.WriteString(strconv.Itoa(-res.Modifier))
+	}
+
+	b.WriteString(" = ")
+	b.WriteString(strconv.Itoa(res.Roll))
+
+	return b.String()
+}
+
+// writeInts writes a comma-separated list of ints to b.
+func writeInts(b *strings.Builder, values []int) {
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.Itoa(v))
+	}
+}
@@ -2,21 +2,11 @@ package dice
 
 import (
 	"errors"
-	"math/rand"
-	"regexp"
-	"strconv"
-	"time"
 )
 
-// Ensure pseudo-random rolls.
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 var (
 	ParseFailure = errors.New("Error: Roll string could not be parsed.")
 	DieSizeError = errors.New("Error: Die size is invalid. A die must have at least 2 sides.")
-	interpreter  = regexp.MustCompile(`(\d*)[dD](\d+)\s?([\+\-]\d+)?`)
 )
 
 const (
@@ -34,6 +24,29 @@ type Result struct {
 	Max    int
 	Avg    float64
 	String string
+
+	// Dice holds the face value of each die that contributed to Roll,
+	// in the order it was rolled. For exploding dice, every roll in an
+	// explosion chain is included. For keep/drop modifiers, only the
+	// surviving dice are listed here; the rest are in Dropped.
+	Dice []int
+
+	// Dropped holds the face value of each die that was removed by a
+	// keep/drop modifier (NdMkhK, NdMklK, NdMdhK, NdMdlK). It is empty
+	// for rolls that don't use one of those modifiers.
+	Dropped []int
+
+	// Modifier is the combined value of the flat, non-dice terms in the
+	// roll expression, such as the +3 in "2d6+3". It is already
+	// included in Roll; it's broken out separately so callers can
+	// render a transcript such as "[4, 6] + 3 = 13".
+	Modifier int
+
+	// diceNeg marks, in parallel with Dice, which of those dice came
+	// from a term that was subtracted (e.g. the 1d6 in "1d8 - 1d6"), so
+	// Format can render them on the correct side of the expression. When
+	// it's nil, Format treats every die as added.
+	diceNeg []bool
 }
 
 // Results represents a set of one or more
@@ -62,7 +75,18 @@ func newResults(n int) *Results {
 //		"D100"
 //		"4d7 -18"
 func SimpleRoll(roll string) (int, error) {
-	results, err := doRoll(roll, 1)
+	return defaultRoller.SimpleRoll(roll)
+}
+
+// SimpleRoll takes a roll string, parses it, and returns the roll
+// result and an error, using r's Source for randomness.
+//
+// Some example roll strings follow:
+//		"1d6"
+//		"D100"
+//		"4d7 -18"
+func (r *Roller) SimpleRoll(roll string) (int, error) {
+	results, err := r.doRoll(roll, 1)
 	if err != nil {
 		return 0, err
 	}
@@ -80,7 +104,18 @@ func SimpleRoll(roll string) (int, error) {
 //		"D100"
 //		"4d7 -18"
 func Roll(roll string) (result *Result, err error) {
-	results, err := doRoll(roll, 1)
+	return defaultRoller.Roll(roll)
+}
+
+// Roll takes a roll string, parses it, and returns the roll result and
+// an error, using r's Source for randomness.
+//
+// Some example roll strings follow:
+//		"1d6"
+//		"D100"
+//		"4d7 -18"
+func (r *Roller) Roll(roll string) (result *Result, err error) {
+	results, err := r.doRoll(roll, 1)
 	if err != nil {
 		return nil, err
 	}
@@ -94,8 +129,9 @@ func Roll(roll string) (result *Result, err error) {
 // and returns the roll results and an error.
 //
 // The roll string may contain multiple
-// rolls, separated by any non-numberical
-// character.
+// expressions, separated by commas. Each
+// expression may itself combine several
+// dice terms with + and -.
 //
 // Some example roll strings follow:
 //
@@ -103,73 +139,52 @@ func Roll(roll string) (result *Result, err error) {
 //		"D100"
 //		"4d7 -18"
 //		"1d6 +3, 2d2"
+//		"2d6 + 1d8 - 3 + 4d4kh2"
 func RollAll(roll string) (results *Results, err error) {
-	return doRoll(roll, ALL_MATCHES)
+	return defaultRoller.RollAll(roll)
+}
+
+// RollAll takes a roll string, parses it, and returns the roll results
+// and an error, using r's Source for randomness.
+//
+// The roll string may contain multiple expressions, separated by
+// commas. Each expression may itself combine several dice terms with
+// + and -.
+//
+// Some example roll strings follow:
+//
+//		"1d6"
+//		"D100"
+//		"4d7 -18"
+//		"1d6 +3, 2d2"
+//		"2d6 + 1d8 - 3 + 4d4kh2"
+func (r *Roller) RollAll(roll string) (results *Results, err error) {
+	return r.doRoll(roll, ALL_MATCHES)
 }
 
 // doRoll performs the actual rolling.
-func doRoll(roll string, n int) (results *Results, err error) {
-	matches := interpreter.FindAllStringSubmatch(roll, n)
-	if matches == nil {
+func (r *Roller) doRoll(roll string, n int) (results *Results, err error) {
+	exprs := splitExprs(roll)
+	if len(exprs) == 0 {
 		return nil, ParseFailure
 	}
+	if n > 0 && n < len(exprs) {
+		exprs = exprs[:n]
+	}
 
 	// Create the output results and set the minimum
 	// very large so that non-zero results can still
 	// become the minimum.
-	results = newResults(len(matches))
+	results = newResults(len(exprs))
 	results.Min = 1<<31 - 1
 
 	// Iterate through roll strings.
-	for i, match := range matches {
-		result := new(Result)
-
-		// num is the number of dice to roll.
-		num := 1
-		if match[1] != "" {
-			num, err = strconv.Atoi(match[1])
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		// size is the number of faces on the dice.
-		size, err := strconv.Atoi(match[2])
+	for i, expr := range exprs {
+		parsed, err := Parse(expr)
 		if err != nil {
 			return nil, err
 		}
-		if size < 2 {
-			return nil, DieSizeError
-		}
-
-		// mod is the roll modifier.
-		mod := 0
-		if match[3] != "" {
-			mod, err = strconv.Atoi(match[3])
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		// Store the roll string.
-		result.String = match[0]
-
-		// Check whether dice are rolled.
-		if num > 0 {
-			result.Min = (num * 1) + mod
-			result.Max = (num * size) + mod
-			result.Avg = (float64(num) * (float64(size+1) / 2.0)) + float64(mod)
-
-			result.Roll = mod
-			for i := 0; i < num; i++ {
-				result.Roll += rand.Intn(size) + 1
-			}
-		} else {
-			result.Roll = mod
-			result.Min = mod
-			result.Max = mod
-			result.Avg = float64(mod)
-		}
+		result := parsed.Eval(r.Source)
 
 		// Update overall statistics.
 		if result.Min < results.Min {
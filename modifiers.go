@@ -0,0 +1,400 @@
+package dice
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// ExplodeLimit caps how many extra dice an exploding die (NdM!) may add
+// to its chain. Without a cap, a die that explodes on its maximum face
+// (such as d2!) could recurse indefinitely.
+var ExplodeLimit = 100
+
+// keepDropKind identifies which of the NdMkhK / NdMklK / NdMdhK / NdMdlK
+// modifiers, if any, applies to a term.
+type keepDropKind int
+
+const (
+	keepDropNone keepDropKind = iota
+	keepHighest
+	keepLowest
+	dropHighest
+	dropLowest
+)
+
+// rerollKind identifies which of the NdMrX / NdMroX modifiers, if any,
+// applies to a term.
+type rerollKind int
+
+const (
+	rerollNone rerollKind = iota
+	rerollEqual
+	rerollOnceAtMost
+)
+
+// termModifiers describes the extended-notation modifiers attached to a
+// single NdM term.
+type termModifiers struct {
+	explode   bool
+	keepDrop  keepDropKind
+	keepDropN int
+	reroll    rerollKind
+	rerollX   int
+}
+
+// parseModifiers reads the explode, keep/drop and reroll submatches
+// produced by the termExpr regexp.
+func parseModifiers(match []string) (termModifiers, error) {
+	var mods termModifiers
+
+	if match[3] == "!" {
+		mods.explode = true
+	}
+
+	switch match[4] {
+	case "kh":
+		mods.keepDrop = keepHighest
+	case "kl":
+		mods.keepDrop = keepLowest
+	case "dh":
+		mods.keepDrop = dropHighest
+	case "dl":
+		mods.keepDrop = dropLowest
+	}
+	if mods.keepDrop != keepDropNone {
+		n, err := strconv.Atoi(match[5])
+		if err != nil {
+			return mods, err
+		}
+		mods.keepDropN = n
+	}
+
+	switch match[6] {
+	case "r":
+		mods.reroll = rerollEqual
+	case "ro":
+		mods.reroll = rerollOnceAtMost
+	}
+	if mods.reroll != rerollNone {
+		x, err := strconv.Atoi(match[7])
+		if err != nil {
+			return mods, err
+		}
+		mods.rerollX = x
+	}
+
+	return mods, nil
+}
+
+// rollOne rolls a single die of the given size, applying the reroll and
+// explode modifiers. It returns every face value produced, in the order
+// rolled: a single value for a plain die, or several for an exploding
+// chain.
+func (r *Roller) rollOne(size int, mods termModifiers) []int {
+	var values []int
+	exploded := 0
+
+	for {
+		v := r.Source.Intn(size) + 1
+
+		switch mods.reroll {
+		case rerollEqual:
+			if v == mods.rerollX {
+				continue
+			}
+		case rerollOnceAtMost:
+			if v <= mods.rerollX {
+				v = r.Source.Intn(size) + 1
+			}
+		}
+
+		values = append(values, v)
+
+		if mods.explode && v == size && exploded < ExplodeLimit {
+			exploded++
+			continue
+		}
+		break
+	}
+
+	return values
+}
+
+// evalTerm rolls a single NdM term (with its modifiers already parsed)
+// and returns the populated Result, excluding its String, Min, Max and
+// Avg fields — those are constant for a given num/size/mods and are
+// filled in by the caller from a cached termStats instead of being
+// recomputed on every roll.
+func (r *Roller) evalTerm(num, size int, mods termModifiers) *Result {
+	result := new(Result)
+
+	if num <= 0 {
+		return result
+	}
+
+	// slots holds every value rolled for each of the num dice, including
+	// any explosion chains.
+	slots := make([][]int, num)
+	sums := make([]int, num)
+	for i := 0; i < num; i++ {
+		slots[i] = r.rollOne(size, mods)
+		for _, v := range slots[i] {
+			sums[i] += v
+		}
+	}
+
+	if mods.keepDrop == keepDropNone {
+		for i := 0; i < num; i++ {
+			result.Dice = append(result.Dice, slots[i]...)
+			result.Roll += sums[i]
+		}
+	} else {
+		kept, dropped := applyKeepDrop(slots, sums, mods.keepDrop, mods.keepDropN)
+		for _, i := range kept {
+			result.Dice = append(result.Dice, slots[i]...)
+			result.Roll += sums[i]
+		}
+		for _, i := range dropped {
+			result.Dropped = append(result.Dropped, slots[i]...)
+		}
+	}
+
+	return result
+}
+
+// rerollFaceStats returns the minimum, maximum and average face shown
+// by a single die of the given size once its reroll modifier, if any,
+// has been applied (but before any explosion).
+//
+// NdMrX never shows X, which narrows the achievable range and shifts
+// the average. NdMroX can still show any face, since a rerolled-once
+// die isn't restricted further, but the average shifts because a low
+// first roll is replaced by a fresh, unrestricted one.
+func rerollFaceStats(size int, mods termModifiers) (min, max int, avg float64) {
+	min, max = 1, size
+	avg = float64(size+1) / 2.0
+
+	switch mods.reroll {
+	case rerollEqual:
+		x := mods.rerollX
+		if x < 1 || x > size {
+			break
+		}
+		avg = float64(size*(size+1)/2-x) / float64(size-1)
+		if x == 1 {
+			min = 2
+		}
+		if x == size {
+			max = size - 1
+		}
+
+	case rerollOnceAtMost:
+		x := mods.rerollX
+		if x < 1 {
+			break
+		}
+		if x > size {
+			x = size
+		}
+		p := float64(x) / float64(size)
+		highAvg := float64(x+1+size) / 2.0
+		rerollAvg := float64(size+1) / 2.0
+		avg = (1-p)*highAvg + p*rerollAvg
+	}
+
+	return min, max, avg
+}
+
+// explodeProb returns the probability that a single roll, after the
+// reroll modifier (if any) is applied, lands on size and so triggers an
+// explosion.
+func explodeProb(size int, mods termModifiers) float64 {
+	switch mods.reroll {
+	case rerollEqual:
+		x := mods.rerollX
+		if x == size {
+			return 0
+		}
+		if x >= 1 && x <= size {
+			return 1.0 / float64(size-1)
+		}
+		return 1.0 / float64(size)
+
+	case rerollOnceAtMost:
+		x := mods.rerollX
+		if x < 1 {
+			return 1.0 / float64(size)
+		}
+		if x > size {
+			x = size
+		}
+		p := float64(x) / float64(size)
+		prob := p * (1.0 / float64(size))
+		if x < size {
+			prob += 1.0 / float64(size)
+		}
+		return prob
+
+	default:
+		return 1.0 / float64(size)
+	}
+}
+
+// dieStats returns the minimum, maximum and average total of a single
+// die of the given size, including any explosion chain, accounting for
+// the reroll modifier along the way.
+//
+// A single post-reroll roll's own min/max/avg come from
+// rerollFaceStats. Explosion adds another, identically-distributed roll
+// whenever the current one lands on size, which gives the recurrence
+// total = face + P(explode)*total, i.e. total = face / (1 - P(explode)).
+// Bounded by ExplodeLimit, the true maximum is only reached in the
+// limit, so the max below is exact but the average is the limit value,
+// very slightly optimistic for a finite cap.
+func dieStats(size int, mods termModifiers) (min, max int, avg float64) {
+	min, max, avg = rerollFaceStats(size, mods)
+
+	if !mods.explode {
+		return min, max, avg
+	}
+
+	if max < size {
+		// The reroll modifier makes size unreachable, so this die can
+		// never explode.
+		return min, max, avg
+	}
+
+	max = size * (ExplodeLimit + 1)
+	avg = avg / (1 - explodeProb(size, mods))
+	return min, max, avg
+}
+
+// termStats returns the minimum, maximum and average total of an NdM
+// term with the given modifiers, without rolling any dice.
+func termStats(num, size int, mods termModifiers) (min, max int, avg float64) {
+	if num <= 0 {
+		return 0, 0, 0
+	}
+
+	dieMin, dieMax, dieAvg := dieStats(size, mods)
+
+	if mods.keepDrop == keepDropNone {
+		return num * dieMin, num * dieMax, float64(num) * dieAvg
+	}
+
+	keepN := mods.keepDropN
+	if keepN > num {
+		keepN = num
+	}
+	if mods.keepDrop == dropHighest || mods.keepDrop == dropLowest {
+		keepN = num - keepN
+	}
+
+	fromHighest := mods.keepDrop == keepHighest || mods.keepDrop == dropLowest
+	return keepN * dieMin, keepN * dieMax, keepDropAvg(num, size, keepN, fromHighest, mods)
+}
+
+// applyKeepDrop sorts the term's dice by their (possibly exploded) sum
+// and returns the indices of the kept and dropped slots, in roll order.
+func applyKeepDrop(slots [][]int, sums []int, kind keepDropKind, n int) (kept, dropped []int) {
+	order := make([]int, len(sums))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return sums[order[a]] < sums[order[b]]
+	})
+
+	if n < 0 {
+		n = 0
+	}
+	if n > len(order) {
+		n = len(order)
+	}
+
+	keepSet := make(map[int]bool, len(order))
+	switch kind {
+	case keepHighest:
+		for _, i := range order[len(order)-n:] {
+			keepSet[i] = true
+		}
+	case keepLowest:
+		for _, i := range order[:n] {
+			keepSet[i] = true
+		}
+	case dropHighest:
+		for _, i := range order[:len(order)-n] {
+			keepSet[i] = true
+		}
+	case dropLowest:
+		for _, i := range order[n:] {
+			keepSet[i] = true
+		}
+	}
+
+	for i := range slots {
+		if keepSet[i] {
+			kept = append(kept, i)
+		} else {
+			dropped = append(dropped, i)
+		}
+	}
+	return kept, dropped
+}
+
+// keepDropAvg returns the expected total of the keepN dice that survive
+// a keep/drop modifier applied to NdM with the given reroll/explode
+// modifiers: the keepN highest of the N rolls if fromHighest is true
+// (as for khK and dlK), otherwise the keepN lowest (as for klK and
+// dhK).
+//
+// There's a closed form for the average of an order statistic, but it's
+// unwieldy to compute exactly for arbitrary N, M and K, so this
+// estimates it instead by sampling a large number of simulated NdM
+// rolls — via the same rollOne used for real rolls, so reroll and
+// explosion are reproduced exactly — with a dedicated,
+// deterministically-seeded generator. The result is an approximation,
+// not an exact value.
+func keepDropAvg(num, size, keepN int, fromHighest bool, mods termModifiers) float64 {
+	const samples = 20000
+
+	src := rand.New(rand.NewSource(int64(num)*1000000 + int64(size)*1000 + int64(keepN)))
+	sampler := &Roller{Source: randAdapter{src}}
+	total := 0.0
+	rolls := make([]int, num)
+
+	for s := 0; s < samples; s++ {
+		for i := range rolls {
+			die := 0
+			for _, v := range sampler.rollOne(size, mods) {
+				die += v
+			}
+			rolls[i] = die
+		}
+		sort.Ints(rolls)
+
+		var kept []int
+		if fromHighest {
+			kept = rolls[num-keepN:]
+		} else {
+			kept = rolls[:keepN]
+		}
+		for _, v := range kept {
+			total += float64(v)
+		}
+	}
+
+	return total / float64(samples)
+}
+
+// randAdapter adapts a *rand.Rand to the Source interface, so that
+// rollOne can be reused to sample dice with a private,
+// deterministically-seeded generator.
+type randAdapter struct {
+	r *rand.Rand
+}
+
+func (a randAdapter) Intn(n int) int {
+	return a.r.Intn(n)
+}
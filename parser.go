@@ -0,0 +1,255 @@
+package dice
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Expr is a parsed roll expression. It can be evaluated against a
+// Source as many times as needed without reparsing, and its statistics
+// can be inspected without rolling anything.
+type Expr interface {
+	// Eval rolls the expression using source for randomness and
+	// returns the result.
+	Eval(source Source) *Result
+
+	// Stats returns the minimum, maximum and average total of the
+	// expression, without rolling anything.
+	Stats() (min, max int, avg float64)
+}
+
+// termExpr matches a single dice term, such as "4d6kh3", with no
+// leading sign and no trailing arithmetic modifier.
+var termExpr = regexp.MustCompile(`^(\d*)[dD](\d+)(!)?(?:(kh|kl|dh|dl)(\d+))?(?:(ro|r)(\d+))?`)
+
+// constExpr matches a bare integer constant.
+var constExpr = regexp.MustCompile(`^\d+`)
+
+// diceExprNode is the Expr for a single NdM term. Its statistics are
+// constant for a given num/size/mods — for keep/drop terms they're
+// estimated by sampling thousands of simulated rolls — so they're
+// computed once, lazily, and cached rather than recomputed on every
+// Eval or Stats call.
+type diceExprNode struct {
+	num, size int
+	mods      termModifiers
+
+	statsOnce sync.Once
+	min, max  int
+	avg       float64
+}
+
+func (d *diceExprNode) Eval(source Source) *Result {
+	r := NewRoller(source)
+	result := r.evalTerm(d.num, d.size, d.mods)
+	result.Min, result.Max, result.Avg = d.Stats()
+	return result
+}
+
+func (d *diceExprNode) Stats() (min, max int, avg float64) {
+	d.statsOnce.Do(func() {
+		d.min, d.max, d.avg = termStats(d.num, d.size, d.mods)
+	})
+	return d.min, d.max, d.avg
+}
+
+// constExprNode is the Expr for a bare integer constant.
+type constExprNode struct {
+	value int
+}
+
+func (c *constExprNode) Eval(source Source) *Result {
+	return &Result{Roll: c.value, Min: c.value, Max: c.value, Avg: float64(c.value), Modifier: c.value}
+}
+
+func (c *constExprNode) Stats() (min, max int, avg float64) {
+	return c.value, c.value, float64(c.value)
+}
+
+// sumTerm is one term of a sumExprNode, together with the sign it's
+// added with.
+type sumTerm struct {
+	expr   Expr
+	negate bool
+}
+
+// sumExprNode is the Expr for a full roll expression: one or more
+// dice terms and constants, combined with + and -.
+type sumExprNode struct {
+	text  string
+	terms []sumTerm
+}
+
+func (e *sumExprNode) Eval(source Source) *Result {
+	result := &Result{String: e.text}
+
+	for _, t := range e.terms {
+		sub := t.expr.Eval(source)
+		if t.negate {
+			result.Roll -= sub.Roll
+			result.Modifier -= sub.Modifier
+		} else {
+			result.Roll += sub.Roll
+			result.Modifier += sub.Modifier
+		}
+		result.Dice = append(result.Dice, sub.Dice...)
+		result.Dropped = append(result.Dropped, sub.Dropped...)
+		for range sub.Dice {
+			result.diceNeg = append(result.diceNeg, t.negate)
+		}
+	}
+
+	result.Min, result.Max, result.Avg = e.Stats()
+	return result
+}
+
+func (e *sumExprNode) Stats() (min, max int, avg float64) {
+	for _, t := range e.terms {
+		tmin, tmax, tavg := t.expr.Stats()
+		if t.negate {
+			min -= tmax
+			max -= tmin
+			avg -= tavg
+		} else {
+			min += tmin
+			max += tmax
+			avg += tavg
+		}
+	}
+	return min, max, avg
+}
+
+// Parse parses a single roll expression, such as "2d6 + 1d8 - 3 +
+// 4d4kh2", and returns its AST. The returned Expr can be evaluated
+// repeatedly via Eval without reparsing.
+//
+// Parse does not accept comma-separated lists of expressions; use
+// RollAll for that.
+func Parse(expr string) (Expr, error) {
+	text := strings.TrimSpace(expr)
+	terms, err := parseTerms(text)
+	if err != nil {
+		return nil, err
+	}
+	return &sumExprNode{text: text, terms: terms}, nil
+}
+
+// parseTerms scans s for a sequence of signed dice terms and constants
+// separated by + or -.
+func parseTerms(s string) ([]sumTerm, error) {
+	var terms []sumTerm
+	i := 0
+	negate := false
+	haveSign := false
+
+	for {
+		i = skipSpace(s, i)
+		if i >= len(s) {
+			break
+		}
+
+		if len(terms) > 0 || haveSign {
+			if s[i] != '+' && s[i] != '-' {
+				return nil, ParseFailure
+			}
+		}
+		if s[i] == '+' || s[i] == '-' {
+			negate = s[i] == '-'
+			haveSign = true
+			i++
+			i = skipSpace(s, i)
+		} else {
+			negate = false
+		}
+
+		if i >= len(s) {
+			return nil, ParseFailure
+		}
+
+		rest := s[i:]
+		expr, consumed, err := parseTerm(rest)
+		if err != nil {
+			return nil, err
+		}
+
+		terms = append(terms, sumTerm{expr: expr, negate: negate})
+		i += consumed
+		haveSign = false
+	}
+
+	if len(terms) == 0 {
+		return nil, ParseFailure
+	}
+	return terms, nil
+}
+
+// parseTerm parses a single dice term or integer constant from the
+// start of s, returning the Expr and the number of bytes consumed.
+func parseTerm(s string) (Expr, int, error) {
+	if loc := termExpr.FindStringSubmatchIndex(s); loc != nil && loc[0] == 0 {
+		match := make([]string, len(loc)/2)
+		for i := range match {
+			if loc[2*i] < 0 {
+				continue
+			}
+			match[i] = s[loc[2*i]:loc[2*i+1]]
+		}
+
+		num := 1
+		if match[1] != "" {
+			n, err := strconv.Atoi(match[1])
+			if err != nil {
+				return nil, 0, err
+			}
+			num = n
+		}
+
+		size, err := strconv.Atoi(match[2])
+		if err != nil {
+			return nil, 0, err
+		}
+		if size < 2 {
+			return nil, 0, DieSizeError
+		}
+
+		mods, err := parseModifiers(match)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return &diceExprNode{num: num, size: size, mods: mods}, loc[1], nil
+	}
+
+	if loc := constExpr.FindStringIndex(s); loc != nil && loc[0] == 0 {
+		value, err := strconv.Atoi(s[loc[0]:loc[1]])
+		if err != nil {
+			return nil, 0, err
+		}
+		return &constExprNode{value: value}, loc[1], nil
+	}
+
+	return nil, 0, ParseFailure
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// splitExprs splits a roll string into its top-level, comma-separated
+// expressions, discarding any empty segments.
+func splitExprs(roll string) []string {
+	parts := strings.Split(roll, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
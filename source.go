@@ -0,0 +1,69 @@
+package dice
+
+import (
+	cryptorand "crypto/rand"
+	"math/big"
+	"math/rand"
+	"time"
+)
+
+// Ensure pseudo-random rolls.
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+// Source represents a source of random numbers suitable for rolling
+// dice. Intn behaves like math/rand.Intn: it returns, as an int, a
+// non-negative pseudo-random number in [0,n). It panics if n <= 0.
+type Source interface {
+	Intn(n int) int
+}
+
+// MathSource is a Source backed by the standard library's math/rand
+// package. It is fast, but the sequence of rolls it produces is
+// predictable to anyone who can observe enough of them, so it is not
+// suitable for games where that matters.
+type MathSource struct{}
+
+// Intn implements Source.
+func (MathSource) Intn(n int) int {
+	return rand.Intn(n)
+}
+
+// CryptoSource is a Source backed by crypto/rand. It is slower than
+// MathSource, but produces unbiased, unpredictable rolls, which matters
+// for games where the outcome has real stakes.
+type CryptoSource struct{}
+
+// Intn implements Source.
+func (CryptoSource) Intn(n int) int {
+	result, err := cryptorand.Int(cryptorand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic("dice: CryptoSource: " + err.Error())
+	}
+	return int(result.Int64())
+}
+
+// Roller performs dice rolls using a particular Source of randomness.
+// The zero value is not usable; use NewRoller to create one.
+type Roller struct {
+	Source Source
+}
+
+// NewRoller returns a new Roller which draws its randomness from the
+// given Source.
+func NewRoller(source Source) *Roller {
+	return &Roller{Source: source}
+}
+
+// defaultRoller backs the package-level Roll, SimpleRoll and RollAll
+// functions.
+var defaultRoller = NewRoller(MathSource{})
+
+// SetDefaultSource replaces the Source used by the package-level Roll,
+// SimpleRoll and RollAll functions. This is primarily useful in tests,
+// where a deterministic or otherwise controlled Source can be injected
+// to make roll outcomes predictable.
+func SetDefaultSource(source Source) {
+	defaultRoller.Source = source
+}
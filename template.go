@@ -0,0 +1,89 @@
+package dice
+
+import "text/template"
+
+// FuncMap returns a text/template.FuncMap (also usable with
+// html/template, whose FuncMap is the same underlying type) exposing
+// roll, rollSum, rollMin, rollMax, rollAvg and rollDice, backed by the
+// default Roller. It lets dice expressions be embedded directly in
+// generated content, such as loot tables or NPC stat blocks:
+//
+//	tmpl := template.Must(template.New("loot").Funcs(dice.FuncMap()).Parse(
+//		"You find {{roll \"2d6\"}} gold pieces."))
+func FuncMap() template.FuncMap {
+	return NewFuncMap(defaultRoller)
+}
+
+// NewFuncMap is like FuncMap, but rolls using r instead of the default
+// Roller. This is useful in tests, where r can wrap a deterministic or
+// otherwise controlled Source so that template output is reproducible.
+func NewFuncMap(r *Roller) template.FuncMap {
+	return template.FuncMap{
+		"roll":     r.SimpleRoll,
+		"rollSum":  r.templateRollSum,
+		"rollMin":  r.templateRollMin,
+		"rollMax":  r.templateRollMax,
+		"rollAvg":  r.templateRollAvg,
+		"rollDice": r.templateRollDice,
+	}
+}
+
+// parseFirst parses the first comma-separated expression in s, which
+// is all that rollMin, rollMax, rollAvg and rollDice operate on.
+func (r *Roller) parseFirst(s string) (Expr, error) {
+	exprs := splitExprs(s)
+	if len(exprs) == 0 {
+		return nil, ParseFailure
+	}
+	return Parse(exprs[0])
+}
+
+// templateRollSum returns the combined total of every comma-separated
+// roll in s, for templates that want a single number from multiple
+// independent rolls, e.g. "2d6, 1d8".
+func (r *Roller) templateRollSum(s string) (int, error) {
+	results, err := r.RollAll(s)
+	if err != nil {
+		return 0, err
+	}
+	sum := 0
+	for _, result := range results.Rolls {
+		sum += result.Roll
+	}
+	return sum, nil
+}
+
+func (r *Roller) templateRollMin(s string) (int, error) {
+	expr, err := r.parseFirst(s)
+	if err != nil {
+		return 0, err
+	}
+	min, _, _ := expr.Stats()
+	return min, nil
+}
+
+func (r *Roller) templateRollMax(s string) (int, error) {
+	expr, err := r.parseFirst(s)
+	if err != nil {
+		return 0, err
+	}
+	_, max, _ := expr.Stats()
+	return max, nil
+}
+
+func (r *Roller) templateRollAvg(s string) (float64, error) {
+	expr, err := r.parseFirst(s)
+	if err != nil {
+		return 0, err
+	}
+	_, _, avg := expr.Stats()
+	return avg, nil
+}
+
+func (r *Roller) templateRollDice(s string) ([]int, error) {
+	expr, err := r.parseFirst(s)
+	if err != nil {
+		return nil, err
+	}
+	return expr.Eval(r.Source).Dice, nil
+}